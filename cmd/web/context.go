@@ -0,0 +1,12 @@
+package main
+
+type contextKey string
+
+const routeContextKey = contextKey("route")
+
+// route carries the pattern matched by the inner mux back out to middleware
+// that wraps it. http.StripPrefix hands the mux a copy of the request, so
+// r.Pattern is never set on the request outer middleware sees.
+type route struct {
+	pattern string
+}
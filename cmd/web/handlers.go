@@ -3,31 +3,46 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"runtime"
 	"strconv"
 )
 
-func home(w http.ResponseWriter, r *http.Request) {
+func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
-		http.NotFound(w, r)
+		app.notFound(w, r)
 		return
 	}
 	w.Write([]byte("Hello from Snippetbox"))
 }
 
-func snippetView(w http.ResponseWriter, r *http.Request) {
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.URL.Query().Get("id"))
 	if err != nil || id < 1 {
-		http.NotFound(w, r)
+		app.notFound(w, r)
 		return
 	}
 	fmt.Fprintf(w, "Display a specific snippet with ID %d...", id)
 }
 
-func snippetCreate(w http.ResponseWriter, r *http.Request) {
+func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Allow", http.MethodPost)
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		app.clientError(w, r, http.StatusMethodNotAllowed)
 		return
 	}
 	w.Write([]byte("Create a new snippet..."))
-}
\ No newline at end of file
+}
+
+func (app *application) versionInfo(w http.ResponseWriter, r *http.Request) {
+	data := map[string]string{
+		"version":   version,
+		"commit":    commit,
+		"buildTime": buildTime,
+		"goVersion": runtime.Version(),
+	}
+
+	err := app.writeJSON(w, http.StatusOK, data, http.Header{"Cache-Control": {"no-store"}})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersionInfo(t *testing.T) {
+	app := newTestApplication(t)
+
+	res := serve(t, app.routes(), httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("got status %d; want %d", res.StatusCode, http.StatusOK)
+	}
+	if got := res.Header.Get("Cache-Control"); got != "no-store" {
+		t.Errorf("got Cache-Control %q; want no-store", got)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["goVersion"] != runtime.Version() {
+		t.Errorf("got goVersion %q; want %q", body["goVersion"], runtime.Version())
+	}
+	if body["version"] != version {
+		t.Errorf("got version %q; want %q", body["version"], version)
+	}
+}
+
+// TestSnippetViewHead goes through a real server, since unlike the server a
+// ResponseRecorder doesn't drop the body of a HEAD response.
+func TestSnippetViewHead(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodHead, ts.URL+"/snippet/view?id=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("got status %d; want %d", res.StatusCode, http.StatusOK)
+	}
+	if res.ContentLength != 39 {
+		t.Errorf("got Content-Length %d; want 39", res.ContentLength)
+	}
+	if len(body) != 0 {
+		t.Errorf("got body %q; want none", body)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+	app.writeError(w, r, http.StatusInternalServerError)
+}
+
+func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.writeError(w, r, status)
+}
+
+func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
+}
+
+// writeError sends a JSON error body to XHR requests and clients that prefer
+// JSON, and the plain text status message to everyone else.
+func (app *application) writeError(w http.ResponseWriter, r *http.Request, status int) {
+	if !app.prefersJSON(r) {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	headers := http.Header{"X-Content-Type-Options": {"nosniff"}}
+	err := app.writeJSON(w, status, map[string]string{"error": http.StatusText(status)}, headers)
+	if err != nil {
+		app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// writeJSON sends data as a JSON response with the given status and any extra
+// headers. Output is indented in debug mode to make it easier to read.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	var (
+		js  []byte
+		err error
+	)
+	if app.config.debug {
+		js, err = json.MarshalIndent(data, "", "\t")
+	} else {
+		js, err = json.Marshal(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// prefersJSON reports whether the client should get a JSON response. Clients
+// that name neither application/json nor text/html in their Accept header
+// (including */* and no header at all) get -default-content-type.
+func (app *application) prefersJSON(r *http.Request) bool {
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		return true
+	}
+
+	var jsonQ, htmlQ float64
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+
+	if jsonQ == 0 && htmlQ == 0 {
+		return app.config.defaultContentType == "json"
+	}
+
+	return jsonQ > htmlQ
+}
+
+// urlPath prefixes an app-relative path with the configured base path so
+// generated links and redirects work when mounted under a subpath.
+func (app *application) urlPath(p string) string {
+	return app.config.basePath + p
+}
+
+// redirect sends a 303 See Other, which tells the client to follow up with a
+// GET whatever the original method was. Use it for post-redirect-get so that
+// refreshing the resulting page never resubmits a form; a 302 leaves the
+// method up to the client.
+func redirect(w http.ResponseWriter, r *http.Request, url string) {
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// permanentRedirect sends a 301 Moved Permanently for canonical URL
+// redirects.
+func permanentRedirect(w http.ResponseWriter, r *http.Request, url string) {
+	http.Redirect(w, r, url, http.StatusMovedPermanently)
+}
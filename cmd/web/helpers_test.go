@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefersJSON(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultType    string
+		accept         string
+		xRequestedWith string
+		want           bool
+	}{
+		{"HTML default, wildcard", "html", "*/*", "", false},
+		{"HTML default, no Accept", "html", "", "", false},
+		{"HTML default, explicit JSON", "html", "application/json", "", true},
+		{"JSON default, wildcard", "json", "*/*", "", true},
+		{"JSON default, no Accept", "json", "", "", true},
+		{"JSON default, explicit HTML", "json", "text/html", "", false},
+		{"JSON default, browser", "json", "text/html,application/xhtml+xml,*/*;q=0.8", "", false},
+		{"JSON ranked below HTML", "html", "application/json;q=0.5,text/html", "", false},
+		{"JSON ranked above HTML", "html", "application/json,text/html;q=0.5", "", true},
+		{"XHR", "html", "text/html", "XMLHttpRequest", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.defaultContentType = tt.defaultType
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if tt.xRequestedWith != "" {
+				r.Header.Set("X-Requested-With", tt.xRequestedWith)
+			}
+
+			if got := app.prefersJSON(r); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotFoundResponseFormat(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("XHR", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		r.Header.Set("X-Requested-With", "XMLHttpRequest")
+		res := serve(t, app.routes(), r)
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("got status %d; want %d", res.StatusCode, http.StatusNotFound)
+		}
+		if got := res.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("got Content-Type %q; want application/json", got)
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["error"] != "Not Found" {
+			t.Errorf("got error %q; want %q", body["error"], "Not Found")
+		}
+	})
+
+	t.Run("Browser", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		r.Header.Set("Accept", "text/html")
+		res := serve(t, app.routes(), r)
+
+		if got := res.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+			t.Errorf("got Content-Type %q; want text/plain; charset=utf-8", got)
+		}
+	})
+}
+
+func TestRedirectHelpers(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		redirect func(http.ResponseWriter, *http.Request, string)
+		wantCode int
+	}{
+		{"Post-redirect-get", http.MethodPost, redirect, http.StatusSeeOther},
+		{"Permanent", http.MethodGet, permanentRedirect, http.StatusMovedPermanently},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.redirect(w, r, "/snippet/view?id=1")
+			})
+			res := serve(t, h, httptest.NewRequest(tt.method, "/snippet/create", nil))
+
+			if res.StatusCode != tt.wantCode {
+				t.Errorf("got status %d; want %d", res.StatusCode, tt.wantCode)
+			}
+			if got := res.Header.Get("Location"); got != "/snippet/view?id=1" {
+				t.Errorf("got Location %q; want %q", got, "/snippet/view?id=1")
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	app := newTestApplication(t)
+
+	rr := httptest.NewRecorder()
+	headers := http.Header{"Location": {"/snippet/view?id=1"}}
+	err := app.writeJSON(rr, http.StatusCreated, map[string]int{"id": 1}, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := rr.Result()
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("got status %d; want %d", res.StatusCode, http.StatusCreated)
+	}
+	if got := res.Header.Get("Location"); got != "/snippet/view?id=1" {
+		t.Errorf("got Location %q; want %q", got, "/snippet/view?id=1")
+	}
+	if got := res.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q; want application/json", got)
+	}
+	if got := rr.Body.String(); got != "{\"id\":1}\n" {
+		t.Errorf("got body %q; want %q", got, "{\"id\":1}\n")
+	}
+}
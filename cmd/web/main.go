@@ -1,15 +1,141 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 )
+
+// Build metadata, overridden at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildTime=...".
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+type config struct {
+	debug                bool
+	logLevel             slog.Level
+	timingHeader         bool
+	basePath             string
+	trustedOrigins       []string
+	slowRequestThreshold time.Duration
+	maxConcurrent        int
+	maxConcurrentWait    time.Duration
+	shutdownTimeout      time.Duration
+	maxHeaderBytes       int
+	maxURILength         int
+	defaultContentType   string
+	canonicalHost        string
+	stripWWW             bool
+	forceWWW             bool
+}
+
+// LogValue implements slog.LogValuer. Any secrets added to config must be
+// masked here before they reach the logs.
+func (cfg config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("debug", cfg.debug),
+		slog.String("logLevel", cfg.logLevel.String()),
+		slog.Bool("timingHeader", cfg.timingHeader),
+		slog.String("basePath", cfg.basePath),
+		slog.Any("trustedOrigins", cfg.trustedOrigins),
+		slog.Duration("slowRequestThreshold", cfg.slowRequestThreshold),
+		slog.Int("maxConcurrent", cfg.maxConcurrent),
+		slog.Duration("maxConcurrentWait", cfg.maxConcurrentWait),
+		slog.Duration("shutdownTimeout", cfg.shutdownTimeout),
+		slog.Int("maxHeaderBytes", cfg.maxHeaderBytes),
+		slog.Int("maxURILength", cfg.maxURILength),
+		slog.String("defaultContentType", cfg.defaultContentType),
+		slog.String("canonicalHost", cfg.canonicalHost),
+		slog.Bool("stripWWW", cfg.stripWWW),
+		slog.Bool("forceWWW", cfg.forceWWW),
+	)
+}
+
+type application struct {
+	config   config
+	logger   *slog.Logger
+	logLevel *slog.LevelVar
+}
+
 func main() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", home)
-	mux.HandleFunc("/snippet/view", snippetView)
-	mux.HandleFunc("/snippet/create", snippetCreate)
-	log.Print("Starting server on :4000")
-	err := http.ListenAndServe(":4000", mux)
-	log.Fatal(err)
-}
\ No newline at end of file
+	var cfg config
+	flag.BoolVar(&cfg.debug, "debug", false, "Enable debug mode (implies -log-level=debug unless set)")
+	flag.TextVar(&cfg.logLevel, "log-level", slog.LevelInfo, "Minimum log level (debug, info, warn or error)")
+	flag.BoolVar(&cfg.timingHeader, "timing-header", false, "Add an X-Response-Time header to responses")
+	flag.StringVar(&cfg.basePath, "base-path", "", "URL path prefix the app is mounted under (e.g. /snippets)")
+	flag.DurationVar(&cfg.slowRequestThreshold, "slow-request-threshold", time.Second, "Log requests slower than this at warn level (0 disables)")
+	flag.IntVar(&cfg.maxConcurrent, "max-concurrent", 0, "Maximum number of requests handled at once (0 is unlimited)")
+	flag.DurationVar(&cfg.maxConcurrentWait, "max-concurrent-wait", 0, "How long a request may queue for a free slot before getting a 503 (0 rejects immediately)")
+	flag.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 30*time.Second, "How long to wait for in-flight requests before force-closing connections on shutdown")
+	flag.IntVar(&cfg.maxHeaderBytes, "max-header-bytes", 1<<20, "Maximum size of request headers in bytes")
+	flag.IntVar(&cfg.maxURILength, "max-uri-length", 2048, "Maximum length of the request URI (0 is unlimited)")
+	flag.StringVar(&cfg.defaultContentType, "default-content-type", "html", "Response format for clients that don't ask for HTML or JSON explicitly (html or json)")
+	flag.StringVar(&cfg.canonicalHost, "canonical-host", "", "Host that www and non-www variants are redirected to (e.g. example.com)")
+	flag.BoolVar(&cfg.stripWWW, "strip-www", false, "Redirect www.host to host")
+	flag.BoolVar(&cfg.forceWWW, "force-www", false, "Redirect host to www.host")
+	flag.Func("trusted-origin", "Additional origin (e.g. https://example.com) allowed to submit forms; may be repeated", func(s string) error {
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid origin %q", s)
+		}
+		cfg.trustedOrigins = append(cfg.trustedOrigins, u.Scheme+"://"+strings.ToLower(u.Host))
+		return nil
+	})
+	flag.Parse()
+
+	// -debug implies debug-level logging unless -log-level was given too.
+	if cfg.debug && !isFlagSet("log-level") {
+		cfg.logLevel = slog.LevelDebug
+	}
+
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.logLevel)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+
+	if cfg.defaultContentType != "html" && cfg.defaultContentType != "json" {
+		logger.Error("default content type must be html or json", "defaultContentType", cfg.defaultContentType)
+		os.Exit(1)
+	}
+
+	if cfg.stripWWW && cfg.forceWWW {
+		logger.Error("-strip-www and -force-www can't both be set")
+		os.Exit(1)
+	}
+
+	cfg.basePath = strings.TrimSuffix(cfg.basePath, "/")
+	if cfg.basePath != "" && !strings.HasPrefix(cfg.basePath, "/") {
+		logger.Error("base path must start with a slash", "basePath", cfg.basePath)
+		os.Exit(1)
+	}
+
+	app := &application{
+		config:   cfg,
+		logger:   logger,
+		logLevel: logLevel,
+	}
+
+	logger.Debug("effective configuration", "config", cfg)
+	err := app.serve()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigLogValue(t *testing.T) {
+	cfg := config{
+		debug:                true,
+		logLevel:             slog.LevelDebug,
+		basePath:             "/snippets",
+		trustedOrigins:       []string{"https://example.com"},
+		slowRequestThreshold: time.Second,
+		defaultContentType:   "json",
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Info("effective configuration", "config", cfg)
+
+	for _, want := range []string{
+		"config.debug=true",
+		"config.logLevel=DEBUG",
+		"config.basePath=/snippets",
+		"config.trustedOrigins=[https://example.com]",
+		"config.slowRequestThreshold=1s",
+		"config.defaultContentType=json",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log %q doesn't contain %q", buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// maxBodyBytes caps how much of a request body is buffered for logging.
+const maxBodyBytes = 1_048_576
+
+var redactedKeys = map[string]bool{
+	"password":   true,
+	"current":    true,
+	"new":        true,
+	"csrf_token": true,
+}
+
+func (app *application) logRequestBody(next http.Handler) http.Handler {
+	if !app.config.debug {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+		if err != nil {
+			app.logger.Error("could not read request body", "error", err.Error())
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(buf), r.Body))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(buf) > maxBodyBytes {
+			app.logger.Debug("request body too large to log", "method", r.Method, "uri", r.URL.RequestURI())
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(buf), r.Body))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(buf))
+		app.logger.Debug("request body", "method", r.Method, "uri", r.URL.RequestURI(), "body", redactBody(r.Header.Get("Content-Type"), buf))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func redactBody(contentType string, body []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "[unparseable form body]"
+		}
+		for key := range values {
+			if redactedKeys[strings.ToLower(key)] {
+				values[key] = []string{"[REDACTED]"}
+			}
+		}
+		return values.Encode()
+	case "application/json":
+		var data any
+		if err := json.Unmarshal(body, &data); err != nil {
+			return "[unparseable JSON body]"
+		}
+		redacted, err := json.Marshal(redactJSON(data))
+		if err != nil {
+			return "[unparseable JSON body]"
+		}
+		return string(redacted)
+	default:
+		return "[body omitted]"
+	}
+}
+
+func redactJSON(data any) any {
+	switch v := data.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if redactedKeys[strings.ToLower(key)] {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redactJSON(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value)
+		}
+	}
+	return data
+}
+
+// canonicalRedirect permanently redirects GET and HEAD requests to their
+// canonical URL in a single hop, and passes everything else on to next. The
+// host is normalised according to -canonical-host and -strip-www/-force-www,
+// and a trailing slash is removed from "/foo/" unless the path belongs to a
+// subtree route (such as "/static/") registered on mux.
+func (app *application) canonicalRedirect(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		host := app.canonicalHost(r.Host)
+
+		// Cleaning (rather than just trimming) the path collapses leading
+		// slashes, so "//evil.com/" can't become a protocol-relative Location.
+		p := r.URL.Path
+		if p != "/" && strings.HasSuffix(p, "/") {
+			if _, pattern := mux.Handler(r); pattern == "/" {
+				p = path.Clean(p)
+			}
+		}
+
+		if host == r.Host && p == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A host change uses a scheme-relative URL so that HTTPS clients
+		// behind a TLS-terminating proxy aren't downgraded to HTTP.
+		target := url.URL{Path: app.urlPath(p), RawQuery: r.URL.RawQuery}
+		if host != r.Host {
+			target.Host = host
+		}
+		permanentRedirect(w, r, target.String())
+	})
+}
+
+// recordRoute serves the request with mux, first saving the pattern it matches
+// into the route that responseTime put in the request context.
+func (app *application) recordRoute(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rt, ok := r.Context().Value(routeContextKey).(*route); ok {
+			_, rt.pattern = mux.Handler(r)
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// canonicalHost returns the form of host that requests should be redirected
+// to. Hostnames are compared case-insensitively and without their port.
+// Hosts that aren't a www or non-www variant of -canonical-host are returned
+// unchanged, and www is never added to IP addresses or single-label names like
+// localhost.
+func (app *application) canonicalHost(host string) string {
+	if app.config.canonicalHost == "" && !app.config.stripWWW && !app.config.forceWWW {
+		return host
+	}
+
+	hostname, port := splitHostPort(host)
+	hostname = strings.ToLower(hostname)
+
+	want := hostname
+	if app.config.canonicalHost != "" {
+		var wantPort string
+		want, wantPort = splitHostPort(strings.ToLower(app.config.canonicalHost))
+		if wantPort != "" {
+			port = wantPort
+		}
+	}
+
+	bare := strings.TrimPrefix(want, "www.")
+	if strings.TrimPrefix(hostname, "www.") != bare {
+		return host
+	}
+
+	switch {
+	case app.config.stripWWW:
+		want = bare
+	case app.config.forceWWW && net.ParseIP(bare) == nil && strings.Contains(bare, "."):
+		want = "www." + bare
+	}
+
+	if port != "" {
+		return net.JoinHostPort(want, port)
+	}
+	return want
+}
+
+func splitHostPort(hostport string) (host, port string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport, ""
+	}
+	return host, port
+}
+
+// timingResponseWriter records the response status and, when setHeader is
+// true, sets the X-Response-Time header just before the status line is
+// written, since headers can't be changed after that.
+type timingResponseWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	setHeader   bool
+	status      int
+	wroteHeader bool
+}
+
+func (tw *timingResponseWriter) WriteHeader(code int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.status = code
+		if tw.setHeader {
+			elapsed := time.Since(tw.start)
+			tw.Header().Set("X-Response-Time", fmt.Sprintf("%.3fms", float64(elapsed)/float64(time.Millisecond)))
+		}
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timingResponseWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timingResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// responseTime adds the X-Response-Time header when -timing-header is set and
+// logs a warning for requests slower than -slow-request-threshold.
+func (app *application) responseTime(next http.Handler) http.Handler {
+	threshold := app.config.slowRequestThreshold
+	if !app.config.timingHeader && threshold <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := &route{}
+		r = r.WithContext(context.WithValue(r.Context(), routeContextKey, rt))
+
+		tw := &timingResponseWriter{ResponseWriter: w, start: time.Now(), setHeader: app.config.timingHeader}
+		next.ServeHTTP(tw, r)
+
+		if !tw.wroteHeader {
+			tw.WriteHeader(http.StatusOK)
+		}
+
+		if elapsed := time.Since(tw.start); threshold > 0 && elapsed > threshold {
+			app.logger.Warn("slow request", "method", r.Method, "pattern", rt.pattern, "uri", r.URL.RequestURI(), "duration", elapsed, "status", tw.status)
+		}
+	})
+}
+
+// limitURILength rejects requests whose URI is longer than -max-uri-length
+// with a 414. Oversized headers are handled by the server's MaxHeaderBytes,
+// which responds with a 431.
+func (app *application) limitURILength(next http.Handler) http.Handler {
+	if app.config.maxURILength <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.RequestURI) > app.config.maxURILength {
+			app.clientError(w, r, http.StatusRequestURITooLong)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkOrigin rejects state-changing requests whose Origin header (or Referer
+// when Origin is absent) is neither same-origin nor a configured trusted
+// origin. Requests carrying neither header are let through.
+func (app *application) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		source := r.Header.Get("Origin")
+		if source == "" {
+			source = r.Header.Get("Referer")
+		}
+		if source == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !app.isTrustedOrigin(r, source) {
+			app.logger.Warn("rejected cross-origin request", "method", r.Method, "uri", r.URL.RequestURI(), "origin", source)
+			app.clientError(w, r, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) isTrustedOrigin(r *http.Request, source string) bool {
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	// url.Parse lowercases the scheme, and trusted origins are stored in
+	// lowercase, so only the host needs folding here.
+	origin := u.Scheme + "://" + strings.ToLower(u.Host)
+	for _, trusted := range app.config.trustedOrigins {
+		if origin == trusted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// limitConcurrency caps the number of in-flight requests at -max-concurrent.
+// Once full, requests wait up to -max-concurrent-wait for a free slot and then
+// get a 503. The /version endpoint is never limited so it can be used to probe
+// a busy instance.
+func (app *application) limitConcurrency(next http.Handler) http.Handler {
+	if app.config.maxConcurrent <= 0 {
+		return next
+	}
+
+	sem := make(chan struct{}, app.config.maxConcurrent)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == app.urlPath("/version") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !acquire(r, sem, app.config.maxConcurrentWait) {
+			w.Header().Set("Retry-After", "1")
+			app.writeError(w, r, http.StatusServiceUnavailable)
+			return
+		}
+		defer func() { <-sem }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func acquire(r *http.Request, sem chan struct{}, wait time.Duration) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+
+	if wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
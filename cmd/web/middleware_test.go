@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "Form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "title=hi&password=hunter2&csrf_token=abc",
+			want:        "csrf_token=%5BREDACTED%5D&password=%5BREDACTED%5D&title=hi",
+		},
+		{
+			name:        "Form mixed case",
+			contentType: "application/x-www-form-urlencoded; charset=utf-8",
+			body:        "Password=hunter2&NEW=hunter3",
+			want:        "NEW=%5BREDACTED%5D&Password=%5BREDACTED%5D",
+		},
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body:        `{"title":"hi","password":"hunter2"}`,
+			want:        `{"password":"[REDACTED]","title":"hi"}`,
+		},
+		{
+			name:        "Nested JSON mixed case",
+			contentType: "application/json",
+			body:        `{"user":{"Password":"hunter2"},"changes":[{"Current":"a","New":"b"}]}`,
+			want:        `{"changes":[{"Current":"[REDACTED]","New":"[REDACTED]"}],"user":{"Password":"[REDACTED]"}}`,
+		},
+		{
+			name:        "Other content type",
+			contentType: "text/plain",
+			body:        "password=hunter2",
+			want:        "[body omitted]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactBody(tt.contentType, []byte(tt.body))
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogRequestBody(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.debug = true
+	logs := captureLogs(t, app)
+
+	const body = "title=hi&Password=hunter2"
+
+	var handlerBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handlerBody = string(b)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/snippet/create", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	serve(t, app.logRequestBody(next), r)
+
+	if handlerBody != body {
+		t.Errorf("handler read %q; want %q", handlerBody, body)
+	}
+	if strings.Contains(logs.String(), "hunter2") {
+		t.Errorf("password logged in clear: %s", logs)
+	}
+	if !strings.Contains(logs.String(), "title=hi") {
+		t.Errorf("body not logged: %s", logs)
+	}
+	if !strings.Contains(logs.String(), "level=DEBUG") {
+		t.Errorf("body not logged at debug level: %s", logs)
+	}
+}
+
+func TestLogRequestBodyInertWithoutDebug(t *testing.T) {
+	app := newTestApplication(t)
+	logs := captureLogs(t, app)
+
+	r := httptest.NewRequest(http.MethodPost, "/snippet/create", strings.NewReader("title=hi"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	serve(t, app.logRequestBody(http.NotFoundHandler()), r)
+
+	if logs.Len() != 0 {
+		t.Errorf("got logs %q; want none", logs)
+	}
+}
+
+func TestCanonicalRedirectTrailingSlash(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", app.home)
+	mux.HandleFunc("/snippet/view", app.snippetView)
+	mux.Handle("/static/", http.NotFoundHandler())
+	handler := app.canonicalRedirect(mux, mux)
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Trailing slash",
+			method:       http.MethodGet,
+			url:          "/snippet/view/?id=1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/view?id=1",
+		},
+		{
+			name:     "Subtree route",
+			method:   http.MethodGet,
+			url:      "/static/",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "POST",
+			method:   http.MethodPost,
+			url:      "/snippet/view/?id=1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Protocol-relative path",
+			method:       http.MethodGet,
+			url:          "//evil.com/",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/evil.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(t, handler, httptest.NewRequest(tt.method, tt.url, nil))
+
+			if res.StatusCode != tt.wantCode {
+				t.Errorf("got status %d; want %d", res.StatusCode, tt.wantCode)
+			}
+			if got := res.Header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("got Location %q; want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestCanonicalRedirectHost(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config
+		host         string
+		wantLocation string
+	}{
+		{
+			name:         "Strip www",
+			cfg:          config{stripWWW: true},
+			host:         "www.example.com",
+			wantLocation: "//example.com/snippet/view?id=1",
+		},
+		{
+			name:         "Force www",
+			cfg:          config{forceWWW: true},
+			host:         "example.com",
+			wantLocation: "//www.example.com/snippet/view?id=1",
+		},
+		{
+			name:         "Strip www with port",
+			cfg:          config{stripWWW: true},
+			host:         "www.example.com:4000",
+			wantLocation: "//example.com:4000/snippet/view?id=1",
+		},
+		{
+			name:         "Canonical host mixed case",
+			cfg:          config{canonicalHost: "example.com"},
+			host:         "WWW.Example.com",
+			wantLocation: "//example.com/snippet/view?id=1",
+		},
+		{
+			name:         "Canonical host with force www",
+			cfg:          config{canonicalHost: "example.com", forceWWW: true},
+			host:         "example.com:4000",
+			wantLocation: "//www.example.com:4000/snippet/view?id=1",
+		},
+		{
+			name: "Unrelated host",
+			cfg:  config{canonicalHost: "example.com"},
+			host: "other.com",
+		},
+		{
+			name: "Force www on localhost",
+			cfg:  config{forceWWW: true},
+			host: "localhost:4000",
+		},
+		{
+			name: "Already canonical",
+			cfg:  config{stripWWW: true},
+			host: "example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config = tt.cfg
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/snippet/view", app.snippetView)
+
+			r := httptest.NewRequest(http.MethodGet, "/snippet/view?id=1", nil)
+			r.Host = tt.host
+			res := serve(t, app.canonicalRedirect(mux, mux), r)
+
+			wantCode := http.StatusMovedPermanently
+			if tt.wantLocation == "" {
+				wantCode = http.StatusOK
+			}
+			if res.StatusCode != wantCode {
+				t.Errorf("got status %d; want %d", res.StatusCode, wantCode)
+			}
+			if got := res.Header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("got Location %q; want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.trustedOrigins = []string{"https://trusted.example"}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	handler := app.checkOrigin(ok)
+
+	tests := []struct {
+		name     string
+		method   string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{"Cross-origin POST", http.MethodPost, "Origin", "https://evil.example", http.StatusForbidden},
+		{"Same-origin POST", http.MethodPost, "Origin", "http://example.com", http.StatusOK},
+		{"Same-origin POST with mixed-case host", http.MethodPost, "Origin", "http://EXAMPLE.com", http.StatusOK},
+		{"Trusted origin", http.MethodPost, "Origin", "https://trusted.example", http.StatusOK},
+		{"Trusted origin with mixed case", http.MethodPost, "Origin", "HTTPS://Trusted.Example", http.StatusOK},
+		{"Trusted host with wrong scheme", http.MethodPost, "Origin", "http://trusted.example", http.StatusForbidden},
+		{"Cross-origin Referer", http.MethodPost, "Referer", "https://evil.example/form", http.StatusForbidden},
+		{"Same-origin Referer", http.MethodPost, "Referer", "http://example.com/snippet/create", http.StatusOK},
+		{"Null origin", http.MethodPost, "Origin", "null", http.StatusForbidden},
+		{"No origin or Referer", http.MethodPost, "", "", http.StatusOK},
+		{"Cross-origin GET", http.MethodGet, "Origin", "https://evil.example", http.StatusOK},
+		{"Cross-origin DELETE", http.MethodDelete, "Origin", "https://evil.example", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/snippet/create", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			res := serve(t, handler, r)
+
+			if res.StatusCode != tt.wantCode {
+				t.Errorf("got status %d; want %d", res.StatusCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSlowRequestLog(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.slowRequestThreshold = time.Millisecond
+	logs := captureLogs(t, app)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {})
+
+	handler := app.responseTime(http.StripPrefix("/snippets", app.recordRoute(mux)))
+	serve(t, handler, httptest.NewRequest(http.MethodGet, "/snippets/slow", nil))
+
+	for _, want := range []string{"level=WARN", `msg="slow request"`, "pattern=/slow", "status=418", "duration="} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log %q doesn't contain %q", logs, want)
+		}
+	}
+
+	logs.Reset()
+	app.config.slowRequestThreshold = time.Hour
+	handler = app.responseTime(http.StripPrefix("/snippets", app.recordRoute(mux)))
+	serve(t, handler, httptest.NewRequest(http.MethodGet, "/snippets/fast", nil))
+
+	if logs.Len() != 0 {
+		t.Errorf("got logs %q for a fast request; want none", logs)
+	}
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	tests := []struct {
+		name      string
+		wait      time.Duration
+		releaseIn time.Duration
+		url       string
+		wantCode  int
+	}{
+		{"Full", 0, time.Hour, "/", http.StatusServiceUnavailable},
+		{"Version bypasses limit", 0, time.Hour, "/version", http.StatusOK},
+		{"Queued until slot frees", time.Second, 20 * time.Millisecond, "/", http.StatusOK},
+		{"Queue timeout", 20 * time.Millisecond, time.Hour, "/", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.maxConcurrent = 1
+			app.config.maxConcurrentWait = tt.wait
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			handler := app.limitConcurrency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/block" {
+					close(started)
+					<-release
+				}
+			}))
+
+			done := make(chan struct{})
+			go func() {
+				serve(t, handler, httptest.NewRequest(http.MethodGet, "/block", nil))
+				close(done)
+			}()
+			<-started
+
+			timer := time.AfterFunc(tt.releaseIn, func() { close(release) })
+			defer func() {
+				if timer.Stop() {
+					close(release)
+				}
+				<-done
+			}()
+
+			res := serve(t, handler, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if res.StatusCode != tt.wantCode {
+				t.Errorf("got status %d; want %d", res.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusServiceUnavailable && res.Header.Get("Retry-After") == "" {
+				t.Error("missing Retry-After header")
+			}
+		})
+	}
+}
+
+func TestAcquireCanceledContext(t *testing.T) {
+	sem := make(chan struct{}, 1)
+	sem <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	if acquire(r, sem, time.Hour) {
+		t.Error("acquired a slot after the request was canceled")
+	}
+}
+
+func TestResponseTimeHeader(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	silent := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name         string
+		timingHeader bool
+		handler      http.Handler
+		wantHeader   bool
+	}{
+		{"Enabled", true, ok, true},
+		{"Enabled, no body written", true, silent, true},
+		{"Disabled", false, ok, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.timingHeader = tt.timingHeader
+
+			res := serve(t, app.responseTime(tt.handler), httptest.NewRequest(http.MethodGet, "/", nil))
+			got := res.Header.Get("X-Response-Time")
+
+			if !tt.wantHeader {
+				if got != "" {
+					t.Errorf("got X-Response-Time %q; want none", got)
+				}
+				return
+			}
+
+			if _, err := time.ParseDuration(got); err != nil {
+				t.Errorf("X-Response-Time %q doesn't parse as a duration: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestLimitURILength(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.maxURILength = 32
+	handler := app.limitURILength(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+	}{
+		{"Short path", "/snippet/view?id=1", http.StatusOK},
+		{"Long path", "/" + strings.Repeat("a", 40), http.StatusRequestURITooLong},
+		{"Long query", "/snippet/view?id=" + strings.Repeat("1", 40), http.StatusRequestURITooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(t, handler, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if res.StatusCode != tt.wantCode {
+				t.Errorf("got status %d; want %d", res.StatusCode, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "net/http"
+
+func (app *application) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", app.home)
+	mux.HandleFunc("/snippet/view", app.snippetView)
+	mux.HandleFunc("/snippet/create", app.snippetCreate)
+	mux.HandleFunc("/version", app.versionInfo)
+
+	var handler http.Handler = app.canonicalRedirect(mux, app.recordRoute(mux))
+	if app.config.basePath != "" {
+		base := http.NewServeMux()
+		base.Handle(app.config.basePath+"/", http.StripPrefix(app.config.basePath, handler))
+		base.HandleFunc("/", app.notFound)
+		handler = base
+	}
+
+	return app.responseTime(app.limitURILength(app.limitConcurrency(app.logRequestBody(app.checkOrigin(handler)))))
+}
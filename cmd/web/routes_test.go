@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.basePath = "/snippets"
+	handler := app.routes()
+
+	tests := []struct {
+		name         string
+		url          string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			// ServeMux's status code for this redirect varies between Go
+			// versions, so any 3xx is accepted.
+			name:         "Prefix without slash",
+			url:          "/snippets",
+			wantLocation: "/snippets/",
+		},
+		{
+			name:     "Home",
+			url:      "/snippets/",
+			wantCode: http.StatusOK,
+			wantBody: "Hello from Snippetbox",
+		},
+		{
+			name:     "Snippet view",
+			url:      "/snippets/snippet/view?id=2",
+			wantCode: http.StatusOK,
+			wantBody: "Display a specific snippet with ID 2...",
+		},
+		{
+			name:     "Outside prefix",
+			url:      "/snippet/view?id=2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Redirect keeps prefix",
+			url:          "/snippets/snippet/view/?id=2",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippets/snippet/view?id=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(t, handler, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			switch {
+			case tt.wantCode == 0:
+				if res.StatusCode < 300 || res.StatusCode > 399 {
+					t.Errorf("got status %d; want a redirect", res.StatusCode)
+				}
+			case res.StatusCode != tt.wantCode:
+				t.Errorf("got status %d; want %d", res.StatusCode, tt.wantCode)
+			}
+			if got := res.Header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("got Location %q; want %q", got, tt.wantLocation)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("got body %q; want it to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
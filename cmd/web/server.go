@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+var errForcedShutdown = errors.New("shutdown timed out: remaining connections were force-closed")
+
+func (app *application) serve() error {
+	var openConns atomic.Int64
+
+	srv := &http.Server{
+		Addr:           ":4000",
+		Handler:        app.routes(),
+		ErrorLog:       slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		MaxHeaderBytes: app.config.maxHeaderBytes,
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			switch state {
+			case http.StateNew:
+				openConns.Add(1)
+			case http.StateHijacked, http.StateClosed:
+				openConns.Add(-1)
+			}
+		},
+	}
+
+	shutdownErr := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.Info("shutting down server", "signal", s.String(), "timeout", app.config.shutdownTimeout)
+		shutdownErr <- app.shutdown(srv, &openConns)
+	}()
+
+	app.logger.Info("starting server", "addr", srv.Addr, "logLevel", app.logLevel.Level())
+
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server", "addr", srv.Addr)
+	return nil
+}
+
+// shutdown gracefully drains srv, force-closing any connections still open
+// once the shutdown timeout elapses. It returns errForcedShutdown in that case
+// so callers can tell a clean drain from a forced one.
+func (app *application) shutdown(srv *http.Server, openConns *atomic.Int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.logger.Warn("shutdown timed out, force-closing connections", "open", openConns.Load())
+		if err := srv.Close(); err != nil {
+			return err
+		}
+		return errForcedShutdown
+	}
+
+	return err
+}
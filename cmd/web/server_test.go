@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func startTestServer(t *testing.T, h http.Handler) (*http.Server, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: h}
+	go srv.Serve(ln)
+
+	return srv, "http://" + ln.Addr().String()
+}
+
+func TestShutdownForcesCloseAfterTimeout(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.shutdownTimeout = 50 * time.Millisecond
+
+	started := make(chan struct{})
+	srv, url := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(time.Second)
+	}))
+
+	go http.Get(url)
+	<-started
+
+	var openConns atomic.Int64
+	openConns.Store(1)
+
+	start := time.Now()
+	err := app.shutdown(srv, &openConns)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, errForcedShutdown) {
+		t.Errorf("got error %v; want %v", err, errForcedShutdown)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("shutdown took %s; want about %s", elapsed, app.config.shutdownTimeout)
+	}
+}
+
+func TestShutdownDrainsCleanly(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.shutdownTimeout = time.Second
+
+	srv, url := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	var openConns atomic.Int64
+	if err := app.shutdown(srv, &openConns); err != nil {
+		t.Errorf("got error %v; want nil", err)
+	}
+}
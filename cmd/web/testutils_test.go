@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	return &application{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// captureLogs points app's logger at a buffer (at debug level) and returns
+// the buffer.
+func captureLogs(t *testing.T, app *application) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return &buf
+}
+
+func serve(t *testing.T, h http.Handler, r *http.Request) *http.Response {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	return rr.Result()
+}